@@ -0,0 +1,265 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const certDownloadURL = "https://api.mch.weixin.qq.com/v3/certificates"
+
+// ErrUnknownCertSerial 在 CertStore 找不到 serial 对应的平台证书时返回.
+var ErrUnknownCertSerial = errors.New("pay: unknown cert serial no")
+
+// CertStore 根据平台证书序列号(Wechatpay-Serial) 返回对应的微信支付平台证书公钥,
+// 供 ParseNotifyV3 验证 Wechatpay-Signature 使用.
+type CertStore interface {
+	PublicKey(serialNo string) (*rsa.PublicKey, error)
+}
+
+// CertManagerOption 用于定制 CertManager 的可选参数.
+type CertManagerOption func(*CertManager)
+
+// WithCertManagerHTTPClient 指定拉取 /v3/certificates 时使用的 http.Client.
+func WithCertManagerHTTPClient(client *http.Client) CertManagerOption {
+	return func(m *CertManager) {
+		m.httpClient = client
+	}
+}
+
+// WithCertManagerRefreshInterval 指定平台证书的自动刷新周期, 默认 24 小时.
+// 微信支付平台证书一般每年轮换一次, 但同时可能存在新旧两张证书, 所以需要定期刷新缓存.
+func WithCertManagerRefreshInterval(interval time.Duration) CertManagerOption {
+	return func(m *CertManager) {
+		m.refreshInterval = interval
+	}
+}
+
+// CertManager 是 CertStore 的一个自动刷新实现: 启动后立即拉取一次 /v3/certificates,
+// 之后按 refreshInterval 周期性刷新, 并把解密后的平台证书公钥缓存在内存里.
+type CertManager struct {
+	mchID      string
+	serialNo   string          // 商户自身证书序列号, 用于签名 /v3/certificates 请求
+	privateKey *rsa.PrivateKey // 商户自身私钥, 用于签名 /v3/certificates 请求
+	apiV3Key   string
+
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu    sync.RWMutex
+	certs map[string]*rsa.PublicKey
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewCertManager 创建一个 CertManager, 并立即同步拉取一次平台证书.
+//
+//	mchID:      商户号
+//	serialNo:   商户 API 证书序列号
+//	privateKey: 商户 API 证书对应的私钥, 用于给 /v3/certificates 请求签名
+//	apiV3Key:   商户平台设置的 APIv3 密钥, 用于解密下载到的平台证书
+func NewCertManager(mchID, serialNo string, privateKey *rsa.PrivateKey, apiV3Key string, opts ...CertManagerOption) (*CertManager, error) {
+	m := &CertManager{
+		mchID:           mchID,
+		serialNo:        serialNo,
+		privateKey:      privateKey,
+		apiV3Key:        apiV3Key,
+		httpClient:      http.DefaultClient,
+		refreshInterval: 24 * time.Hour,
+		certs:           make(map[string]*rsa.PublicKey),
+		stopCh:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	go m.autoRefresh()
+	return m, nil
+}
+
+// PublicKey 实现 CertStore 接口.
+func (m *CertManager) PublicKey(serialNo string) (*rsa.PublicKey, error) {
+	m.mu.RLock()
+	pub, ok := m.certs[serialNo]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownCertSerial
+	}
+	return pub, nil
+}
+
+// Close 停止后台的自动刷新.
+func (m *CertManager) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+}
+
+func (m *CertManager) autoRefresh() {
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.refresh() // 刷新失败时保留旧证书, 等待下一轮重试
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// certificatesResponse 对应 GET /v3/certificates 的响应.
+type certificatesResponse struct {
+	Data []struct {
+		SerialNo           string `json:"serial_no"`
+		EncryptCertificate struct {
+			Algorithm      string `json:"algorithm"`
+			Nonce          string `json:"nonce"`
+			AssociatedData string `json:"associated_data"`
+			Ciphertext     string `json:"ciphertext"`
+		} `json:"encrypt_certificate"`
+	} `json:"data"`
+}
+
+func (m *CertManager) refresh() error {
+	req, err := http.NewRequest(http.MethodGet, certDownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	authorization, err := m.signRequest(req.Method, "/v3/certificates", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pay: GET /v3/certificates failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	if err := m.verifyCertificatesResponse(resp.Header, body); err != nil {
+		return err
+	}
+
+	var certsResp certificatesResponse
+	if err := json.Unmarshal(body, &certsResp); err != nil {
+		return err
+	}
+
+	certs := make(map[string]*rsa.PublicKey, len(certsResp.Data))
+	for _, item := range certsResp.Data {
+		plaintext, err := aes256gcmDecrypt(
+			m.apiV3Key,
+			item.EncryptCertificate.Nonce,
+			item.EncryptCertificate.AssociatedData,
+			item.EncryptCertificate.Ciphertext,
+		)
+		if err != nil {
+			return err
+		}
+
+		block, _ := pem.Decode(plaintext)
+		if block == nil {
+			return errors.New("pay: invalid platform certificate PEM")
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return err
+		}
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("pay: platform certificate is not RSA")
+		}
+		certs[item.SerialNo] = pub
+	}
+
+	m.mu.Lock()
+	m.certs = certs
+	m.mu.Unlock()
+	return nil
+}
+
+// verifyCertificatesResponse 校验 /v3/certificates 响应自身的 Wechatpay-Signature.
+//
+// 响应里下发的正是平台证书, 所以不能用响应里的证书验证响应本身, 而是要用当前已经缓存、
+// 之前已验证过的证书去验; 只有 m.certs 还是空的第一次拉取(bootstrap) 才没有证书可用,
+// 此时退化为只信任 TLS 通道本身, 后续每一次刷新都必须验签通过才能替换 m.certs.
+func (m *CertManager) verifyCertificatesResponse(headers http.Header, body []byte) error {
+	m.mu.RLock()
+	bootstrapping := len(m.certs) == 0
+	m.mu.RUnlock()
+	if bootstrapping {
+		return nil
+	}
+
+	timestamp := headers.Get("Wechatpay-Timestamp")
+	nonce := headers.Get("Wechatpay-Nonce")
+	signature := headers.Get("Wechatpay-Signature")
+	serial := headers.Get("Wechatpay-Serial")
+	if timestamp == "" || nonce == "" || signature == "" || serial == "" {
+		return errors.New("pay: /v3/certificates response missing Wechatpay-* header")
+	}
+
+	pub, err := m.PublicKey(serial)
+	if err != nil {
+		return err
+	}
+	return verifyNotifyV3Signature(pub, timestamp, nonce, body, signature)
+}
+
+// signRequest 按微信支付 v3 的签名规则构造 Authorization header.
+//
+//	message = method + "\n" + url + "\n" + timestamp + "\n" + nonce + "\n" + body + "\n"
+func (m *CertManager) signRequest(method, canonicalURL string, body []byte) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	message := method + "\n" + canonicalURL + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	h := crypto.SHA256.New()
+	h.Write([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.privateKey, crypto.SHA256, h.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	return fmt.Sprintf(
+		`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		m.mchID, nonce, signature, timestamp, m.serialNo,
+	), nil
+}
@@ -0,0 +1,99 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"container/list"
+	"sync"
+)
+
+// IdempotencyStore 用于记录已经成功处理过的 NotifyId, 避免微信的重试通知被重复处理.
+//
+// 两个方法分别对应"检查"和"提交"两步, 调用方(notifyHandler) 必须只在业务回调成功返回之后
+// 才调用 MarkProcessed; 回调失败时不能标记, 否则微信重试同一个 NotifyId 时会被 AlreadyProcessed
+// 误判为已完成, 直接 ACK "success" 而不再调用业务回调, 造成业务数据永远没有被处理却回复了成功.
+type IdempotencyStore interface {
+	// AlreadyProcessed 返回 true 表示该 notifyId 之前已经成功处理过, 本次应当跳过业务回调直接 ACK.
+	AlreadyProcessed(notifyId string) bool
+
+	// MarkProcessed 把 notifyId 记为已经成功处理, 只应该在业务回调返回 nil 之后调用.
+	MarkProcessed(notifyId string)
+}
+
+// NewLRUIdempotencyStore 返回一个进程内的 IdempotencyStore 默认实现, 最多记住 capacity 个 NotifyId,
+// 超出时淘汰最久未使用的记录. capacity <= 0 时使用默认值 10000.
+func NewLRUIdempotencyStore(capacity int) IdempotencyStore {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &lruIdempotencyStore{
+		capacity: capacity,
+		list:     list.New(),
+		elements: make(map[string]*list.Element, capacity),
+	}
+}
+
+type lruIdempotencyStore struct {
+	mu       sync.Mutex
+	capacity int
+	list     *list.List
+	elements map[string]*list.Element
+}
+
+func (s *lruIdempotencyStore) AlreadyProcessed(notifyId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.elements[notifyId]
+	if !ok {
+		return false
+	}
+	s.list.MoveToFront(elem)
+	return true
+}
+
+func (s *lruIdempotencyStore) MarkProcessed(notifyId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.elements[notifyId]; ok {
+		s.list.MoveToFront(elem)
+		return
+	}
+
+	elem := s.list.PushFront(notifyId)
+	s.elements[notifyId] = elem
+	if s.list.Len() > s.capacity {
+		oldest := s.list.Back()
+		if oldest != nil {
+			s.list.Remove(oldest)
+			delete(s.elements, oldest.Value.(string))
+		}
+	}
+}
+
+// RedisIdempotencyStore 是 IdempotencyStore 基于 Redis 的示例实现.
+// Exists/Set 由调用方按自己的 redis 客户端实现, Set 建议带上一个 ttl(取微信通知重试窗口
+// ——官方文档给出的最长重试时间——之上的安全余量), 避免无限堆积.
+type RedisIdempotencyStore struct {
+	Exists func(notifyId string) (bool, error)
+	Set    func(notifyId string) error
+}
+
+func (s *RedisIdempotencyStore) AlreadyProcessed(notifyId string) bool {
+	processed, err := s.Exists(notifyId)
+	if err != nil {
+		// Redis 不可用时退化为"未处理过", 交给下游的幂等业务逻辑兜底, 避免误拒正常通知.
+		return false
+	}
+	return processed
+}
+
+func (s *RedisIdempotencyStore) MarkProcessed(notifyId string) {
+	// Set 失败只会导致这一条通知重试时被当成"未处理过"再跑一次业务回调, 业务回调本身
+	// 应当是幂等的, 所以这里不需要返回错误给调用方, 静默丢弃即可.
+	_ = s.Set(notifyId)
+}
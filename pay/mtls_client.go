@@ -0,0 +1,328 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// mtlsBaseURL 是需要客户端证书的商户 API 的默认域名.
+const mtlsBaseURL = "https://api.mch.weixin.qq.com"
+
+// ClientOption 用于定制 Client.
+type ClientOption func(*Client)
+
+// WithBaseURL 覆盖默认的 https://api.mch.weixin.qq.com, 用于接入沙箱环境或者代理.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithCACertPool 指定校验微信支付服务端证书所用的 *x509.CertPool.
+// 不指定时使用系统默认的根证书池, 这对 api.mch.weixin.qq.com 已经足够, 仅在需要额外
+// 校验/证书固定(certificate pinning) 的场景下才需要传入自定义的 pool.
+func WithCACertPool(pool *x509.CertPool) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig.RootCAs = pool
+	}
+}
+
+// WithHTTPTimeout 设置底层 http.Client 的超时时间, 默认 30 秒.
+func WithHTTPTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// Client 是需要双向 TLS 客户端证书的商户 API(退款, 企业付款, 撤销订单等) 的调用封装.
+type Client struct {
+	mchID      string
+	baseURL    string
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+}
+
+// NewMTLSClient 用商户 API 证书 cert 创建一个 Client, 用于调用
+// secapi/pay/refund, mmpaymkttransfers/*, secapi/pay/reverse 等要求客户端证书的接口.
+func NewMTLSClient(mchID string, cert tls.Certificate, opts ...ClientOption) *Client {
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	c := &Client{
+		mchID:     mchID,
+		baseURL:   mtlsBaseURL,
+		tlsConfig: tlsConfig,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LoadCertFromFile 从证书/私钥文件加载商户 API 证书, 对应 AddCertFilePath 的用法.
+func LoadCertFromFile(certFile, keyFile string) (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// LoadCertFromPKCS12 从微信支付平台分发的 .p12 证书内容加载商户 API 证书,
+// 对应 AddCertFileContent 的用法; p12 的密码默认就是商户号 mchID.
+func LoadCertFromPKCS12(pfxData []byte, password string) (tls.Certificate, error) {
+	privateKey, cert, err := pkcs12.Decode(pfxData, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  privateKey,
+		Leaf:        cert,
+	}, nil
+}
+
+// RefundRequest 是 secapi/pay/refund 的请求参数.
+type RefundRequest struct {
+	AppId         string `pay:"appid,omitempty"`
+	TransactionId string `pay:"transaction_id,omitempty"`
+	OutTradeNo    string `pay:"out_trade_no,omitempty"`
+	OutRefundNo   string `pay:"out_refund_no"`
+	TotalFee      int    `pay:"total_fee"`
+	RefundFee     int    `pay:"refund_fee"`
+	NotifyUrl     string `pay:"notify_url,omitempty"`
+}
+
+// RefundResponse 是 secapi/pay/refund 的响应参数(已验签).
+type RefundResponse struct {
+	ReturnCode  string `xml:"return_code"`
+	ReturnMsg   string `xml:"return_msg"`
+	ResultCode  string `xml:"result_code"`
+	ErrCode     string `xml:"err_code"`
+	ErrCodeDes  string `xml:"err_code_des"`
+	OutRefundNo string `xml:"out_refund_no"`
+	RefundId    string `xml:"refund_id"`
+	RefundFee   int    `xml:"refund_fee"`
+}
+
+// Refund 发起退款, path 固定为 secapi/pay/refund, 该接口要求客户端证书.
+func (c *Client) Refund(ctx context.Context, paySignKey string, req RefundRequest) (*RefundResponse, error) {
+	resp := new(RefundResponse)
+	if err := c.do(ctx, "/secapi/pay/refund", req, paySignKey, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// QueryRefundRequest 是 pay/refundquery 的请求参数, 四选一.
+type QueryRefundRequest struct {
+	AppId         string `pay:"appid,omitempty"`
+	TransactionId string `pay:"transaction_id,omitempty"`
+	OutTradeNo    string `pay:"out_trade_no,omitempty"`
+	OutRefundNo   string `pay:"out_refund_no,omitempty"`
+	RefundId      string `pay:"refund_id,omitempty"`
+}
+
+// QueryRefundResponse 是 pay/refundquery 的响应参数(已验签).
+// 微信支付可能就同一笔订单返回多笔退款(refund_count 及编号 refund_id_$n 等字段),
+// 这里只暴露最常用的顶层字段, 需要完整信息时可以改用 Response 自行解析.
+type QueryRefundResponse struct {
+	ReturnCode  string `xml:"return_code"`
+	ReturnMsg   string `xml:"return_msg"`
+	ResultCode  string `xml:"result_code"`
+	ErrCode     string `xml:"err_code"`
+	ErrCodeDes  string `xml:"err_code_des"`
+	OutTradeNo  string `xml:"out_trade_no"`
+	RefundCount int    `xml:"refund_count"`
+}
+
+// QueryRefund 查询退款状态, path 固定为 pay/refundquery.
+func (c *Client) QueryRefund(ctx context.Context, paySignKey string, req QueryRefundRequest) (*QueryRefundResponse, error) {
+	resp := new(QueryRefundResponse)
+	if err := c.do(ctx, "/pay/refundquery", req, paySignKey, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReverseRequest 是 secapi/pay/reverse 的请求参数, 二选一.
+type ReverseRequest struct {
+	AppId         string `pay:"appid,omitempty"`
+	TransactionId string `pay:"transaction_id,omitempty"`
+	OutTradeNo    string `pay:"out_trade_no,omitempty"`
+}
+
+// ReverseResponse 是 secapi/pay/reverse 的响应参数(已验签).
+type ReverseResponse struct {
+	ReturnCode string `xml:"return_code"`
+	ReturnMsg  string `xml:"return_msg"`
+	ResultCode string `xml:"result_code"`
+	ErrCode    string `xml:"err_code"`
+	ErrCodeDes string `xml:"err_code_des"`
+	Recall     string `xml:"recall"` // Y-需要继续调用撤销
+}
+
+// Reverse 撤销订单, path 固定为 secapi/pay/reverse, 该接口要求客户端证书.
+func (c *Client) Reverse(ctx context.Context, paySignKey string, req ReverseRequest) (*ReverseResponse, error) {
+	resp := new(ReverseResponse)
+	if err := c.do(ctx, "/secapi/pay/reverse", req, paySignKey, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TransferRequest 是企业付款 mmpaymkttransfers/promotion/transfers 的请求参数.
+type TransferRequest struct {
+	MchAppId       string `pay:"mch_appid"`
+	PartnerTradeNo string `pay:"partner_trade_no"`
+	OpenId         string `pay:"openid"`
+	CheckName      string `pay:"check_name"` // NO_CHECK, FORCE_CHECK
+	ReUserName     string `pay:"re_user_name,omitempty"`
+	Amount         int    `pay:"amount"`
+	Desc           string `pay:"desc"`
+	SpbillCreateIp string `pay:"spbill_create_ip"`
+}
+
+// TransferResponse 是企业付款的响应参数(已验签).
+type TransferResponse struct {
+	ReturnCode     string `xml:"return_code"`
+	ReturnMsg      string `xml:"return_msg"`
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	PaymentNo      string `xml:"payment_no"`
+	PaymentTime    string `xml:"payment_time"`
+}
+
+// Transfer 发起企业付款, path 固定为 mmpaymkttransfers/promotion/transfers, 该接口要求客户端证书.
+func (c *Client) Transfer(ctx context.Context, paySignKey string, req TransferRequest) (*TransferResponse, error) {
+	resp := new(TransferResponse)
+	if err := c.do(ctx, "/mmpaymkttransfers/promotion/transfers", req, paySignKey, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// QueryTransferRequest 是 mmpaymkttransfers/gettransferinfo 的请求参数.
+type QueryTransferRequest struct {
+	AppId          string `pay:"appid"`
+	PartnerTradeNo string `pay:"partner_trade_no"`
+}
+
+// QueryTransferResponse 是企业付款查询的响应参数(已验签).
+type QueryTransferResponse struct {
+	ReturnCode     string `xml:"return_code"`
+	ReturnMsg      string `xml:"return_msg"`
+	ResultCode     string `xml:"result_code"`
+	ErrCode        string `xml:"err_code"`
+	ErrCodeDes     string `xml:"err_code_des"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	DetailId       string `xml:"detail_id"`
+	Status         string `xml:"status"`
+	Reason         string `xml:"reason"`
+	OpenId         string `xml:"openid"`
+	TransferName   string `xml:"transfer_name"`
+	PaymentAmount  int    `xml:"payment_amount"`
+	TransferTime   string `xml:"transfer_time"`
+	PaymentTime    string `xml:"payment_time"`
+}
+
+// QueryTransfer 查询企业付款状态, path 固定为 mmpaymkttransfers/gettransferinfo, 该接口要求客户端证书.
+func (c *Client) QueryTransfer(ctx context.Context, paySignKey string, req QueryTransferRequest) (*QueryTransferResponse, error) {
+	resp := new(QueryTransferResponse)
+	if err := c.do(ctx, "/mmpaymkttransfers/gettransferinfo", req, paySignKey, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// do 统一处理签名, 序列化, 发起 mTLS 请求, 验签和反序列化.
+func (c *Client) do(ctx context.Context, path string, reqStruct interface{}, paySignKey string, respStruct interface{}) error {
+	req, err := NewRequest(reqStruct)
+	if err != nil {
+		return err
+	}
+	req.Values().Set("mch_id", c.mchID)
+	if err := req.Sign(SIGN_TYPE_MD5, paySignKey); err != nil {
+		return err
+	}
+
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "text/xml; charset=utf-8")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+
+	var resp Response
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return err
+	}
+	if resp.Get("return_code") != "SUCCESS" {
+		return fmt.Errorf("pay: %s: %s", resp.Get("return_code"), resp.Get("return_msg"))
+	}
+	if err := verifyResponseSignature(resp, SIGN_TYPE_MD5, paySignKey); err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(respBody, respStruct)
+}
+
+// verifyResponseSignature 按 SignParams 同样的算法重新计算响应报文的签名并做常数时间比较.
+func verifyResponseSignature(resp Response, signType, paySignKey string) error {
+	sign := resp.Get("sign")
+	if sign == "" {
+		return errors.New("pay: response missing sign")
+	}
+
+	values := make(url.Values, len(resp))
+	for k, v := range resp {
+		if k == "sign" {
+			continue
+		}
+		values.Set(k, v)
+	}
+
+	expected, err := SignParams(values, signType, paySignKey)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(sign), []byte(expected)) != 1 {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestVerifyResponseSignature(t *testing.T) {
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+	values := url.Values{
+		"return_code":   {"SUCCESS"},
+		"out_refund_no": {"1217752501201407033233368018"},
+		"refund_fee":    {"101"},
+	}
+	sign, err := SignParams(values, SIGN_TYPE_MD5, paySignKey)
+	if err != nil {
+		t.Fatalf("SignParams: %v", err)
+	}
+
+	resp := Response{}
+	for k := range values {
+		resp[k] = values.Get(k)
+	}
+	resp["sign"] = sign
+
+	if err := verifyResponseSignature(resp, SIGN_TYPE_MD5, paySignKey); err != nil {
+		t.Fatalf("verifyResponseSignature with valid signature: %v", err)
+	}
+
+	tampered := Response{}
+	for k, v := range resp {
+		tampered[k] = v
+	}
+	tampered["refund_fee"] = "999999"
+	if err := verifyResponseSignature(tampered, SIGN_TYPE_MD5, paySignKey); err != ErrSignatureInvalid {
+		t.Fatalf("verifyResponseSignature with tampered field: got %v, want ErrSignatureInvalid", err)
+	}
+
+	missingSign := Response{"return_code": "SUCCESS"}
+	if err := verifyResponseSignature(missingSign, SIGN_TYPE_MD5, paySignKey); err == nil {
+		t.Fatal("verifyResponseSignature with missing sign: want error, got nil")
+	}
+}
+
+func TestClientRefund(t *testing.T) {
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+	const mchID = "10000100"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := url.Values{
+			"return_code":   {"SUCCESS"},
+			"return_msg":    {"OK"},
+			"result_code":   {"SUCCESS"},
+			"out_refund_no": {"OR1217752501201407033233368018"},
+			"refund_id":     {"RF1001"},
+			"refund_fee":    {"101"},
+		}
+		sign, err := SignParams(values, SIGN_TYPE_MD5, paySignKey)
+		if err != nil {
+			t.Fatalf("SignParams: %v", err)
+		}
+		fmt.Fprintf(w, "<xml>"+
+			"<return_code>%s</return_code><return_msg>%s</return_msg>"+
+			"<result_code>%s</result_code>"+
+			"<out_refund_no>%s</out_refund_no><refund_id>%s</refund_id>"+
+			"<refund_fee>%s</refund_fee><sign>%s</sign></xml>",
+			values.Get("return_code"), values.Get("return_msg"), values.Get("result_code"),
+			values.Get("out_refund_no"), values.Get("refund_id"), values.Get("refund_fee"), sign)
+	}))
+	defer server.Close()
+
+	client := NewMTLSClient(mchID, tls.Certificate{}, WithBaseURL(server.URL))
+
+	resp, err := client.Refund(context.Background(), paySignKey, RefundRequest{
+		OutTradeNo:  "1217752501201407033233368018",
+		OutRefundNo: "OR1217752501201407033233368018",
+		TotalFee:    101,
+		RefundFee:   101,
+	})
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if resp.RefundId != "RF1001" {
+		t.Fatalf("RefundId = %q, want %q", resp.RefundId, "RF1001")
+	}
+	if resp.RefundFee != 101 {
+		t.Fatalf("RefundFee = %d, want %d", resp.RefundFee, 101)
+	}
+}
+
+func TestClientRefundRejectsBadSignature(t *testing.T) {
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>"+
+			"<out_refund_no>OR1</out_refund_no><sign>0123456789ABCDEF0123456789ABCDEF</sign></xml>")
+	}))
+	defer server.Close()
+
+	client := NewMTLSClient("10000100", tls.Certificate{}, WithBaseURL(server.URL))
+
+	if _, err := client.Refund(context.Background(), paySignKey, RefundRequest{
+		OutTradeNo:  "1217752501201407033233368018",
+		OutRefundNo: "OR1",
+		TotalFee:    101,
+		RefundFee:   101,
+	}); err != ErrSignatureInvalid {
+		t.Fatalf("Refund with tampered response signature: got %v, want ErrSignatureInvalid", err)
+	}
+}
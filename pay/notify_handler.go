@@ -0,0 +1,158 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// NotifyHandlerFunc 是业务方处理一条已经验签通过的支付通知的回调.
+// 返回 non-nil error 时, NewNotifyHandler 返回的 http.Handler 会向微信回复 "fail", 促使微信重试该通知.
+type NotifyHandlerFunc func(ctx context.Context, n *NotifyURLData) error
+
+// NotifyLogger 用于记录通知处理过程中的关键事件, 方便接入现有的结构化日志.
+// duration 是从收到请求到写出 ACK 所花费的时间, 依赖 WithClock 注入的时钟.
+type NotifyLogger interface {
+	LogNotify(ctx context.Context, n *NotifyURLData, err error, duration time.Duration)
+}
+
+// NotifyLoggerFunc 是 NotifyLogger 的函数适配器.
+type NotifyLoggerFunc func(ctx context.Context, n *NotifyURLData, err error, duration time.Duration)
+
+func (f NotifyLoggerFunc) LogNotify(ctx context.Context, n *NotifyURLData, err error, duration time.Duration) {
+	f(ctx, n, err, duration)
+}
+
+// NotifyOption 用于定制 NewNotifyHandler 返回的 http.Handler.
+type NotifyOption func(*notifyHandler)
+
+// WithIdempotencyStore 指定用于去重的 IdempotencyStore, 默认使用容量 10000 的内存 LRU.
+func WithIdempotencyStore(store IdempotencyStore) NotifyOption {
+	return func(h *notifyHandler) {
+		h.idempotencyStore = store
+	}
+}
+
+// WithSignKeyProvider 让 handler 改用 CheckAndInitWithSignKeyProvider 验签,
+// 从而支持 sign_type=RSA 和多密钥轮换; 指定之后 NewNotifyHandler 的 paySignKey 参数被忽略.
+func WithSignKeyProvider(provider SignKeyProvider) NotifyOption {
+	return func(h *notifyHandler) {
+		h.signKeyProvider = provider
+	}
+}
+
+// WithNotifyLogger 注册一个 NotifyLogger, 在每次通知处理完成后调用(无论成功或失败).
+func WithNotifyLogger(logger NotifyLogger) NotifyOption {
+	return func(h *notifyHandler) {
+		h.logger = logger
+	}
+}
+
+// WithClock 指定 handler 内部用来获取当前时间的函数, 主要用于测试.
+func WithClock(now func() time.Time) NotifyOption {
+	return func(h *notifyHandler) {
+		h.now = now
+	}
+}
+
+// NewNotifyHandler 返回一个可以直接挂载到 http.ServeMux 的支付通知 http.Handler.
+// 它负责解析请求参数, 调用 NotifyURLData.CheckAndInit 验签, 按 NotifyId 去重,
+// 调用业务回调 h, 并向微信回复约定的 ACK 文本, 使得重复通知(重试风暴)不会被重复处理.
+func NewNotifyHandler(paySignKey string, h NotifyHandlerFunc, opts ...NotifyOption) http.Handler {
+	handler := &notifyHandler{
+		paySignKey:       paySignKey,
+		handle:           h,
+		idempotencyStore: NewLRUIdempotencyStore(0),
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
+}
+
+type notifyHandler struct {
+	paySignKey       string
+	signKeyProvider  SignKeyProvider
+	handle           NotifyHandlerFunc
+	idempotencyStore IdempotencyStore
+	logger           NotifyLogger
+	now              func() time.Time
+}
+
+func (h *notifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	start := h.now()
+
+	values, err := h.parseValues(r)
+	if err != nil {
+		h.log(ctx, nil, err, h.now().Sub(start))
+		writeNotifyACK(w, false)
+		return
+	}
+
+	data := new(NotifyURLData)
+	if h.signKeyProvider != nil {
+		err = data.CheckAndInitWithSignKeyProvider(values, h.signKeyProvider)
+	} else {
+		err = data.CheckAndInit(values, h.paySignKey)
+	}
+	if err != nil {
+		h.log(ctx, nil, err, h.now().Sub(start))
+		writeNotifyACK(w, false)
+		return
+	}
+
+	if h.idempotencyStore.AlreadyProcessed(data.NotifyId) {
+		// 之前已经成功处理过, 无需再次触发业务逻辑, 直接确认收到即可.
+		h.log(ctx, data, nil, h.now().Sub(start))
+		writeNotifyACK(w, true)
+		return
+	}
+
+	err = h.handle(ctx, data)
+	if err == nil {
+		// 只有业务回调成功返回才能标记为已处理; 回调失败的通知必须保持"未处理", 这样微信重试时
+		// AlreadyProcessed 仍然返回 false, 回调会被再次调用, 不会因为提前标记而丢失这次业务处理.
+		h.idempotencyStore.MarkProcessed(data.NotifyId)
+	}
+	h.log(ctx, data, err, h.now().Sub(start))
+	writeNotifyACK(w, err == nil)
+}
+
+// parseValues 从请求里取出通知参数: GET 请求用 query string, POST 请求用 r.Form
+// (表单 body 和 query string 合并后的结果, 同名字段 body 里的值排在前面).
+func (h *notifyHandler) parseValues(r *http.Request) (url.Values, error) {
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		return r.Form, nil
+	}
+	return r.URL.Query(), nil
+}
+
+func (h *notifyHandler) log(ctx context.Context, n *NotifyURLData, err error, duration time.Duration) {
+	if h.logger != nil {
+		h.logger.LogNotify(ctx, n, err, duration)
+	}
+}
+
+// writeNotifyACK 按微信通知协议的约定回复 ACK: 处理成功回复 "success" 并返回 200,
+// 否则回复 "fail" 并返回 500, 微信收到非 success 的响应会按策略重试该通知.
+func writeNotifyACK(w http.ResponseWriter, success bool) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if success {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("success"))
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte("fail"))
+}
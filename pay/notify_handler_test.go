@@ -0,0 +1,142 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWriteNotifyACK(t *testing.T) {
+	cases := []struct {
+		success    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{success: true, wantStatus: 200, wantBody: "success"},
+		{success: false, wantStatus: 500, wantBody: "fail"},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		writeNotifyACK(w, c.success)
+
+		if w.Code != c.wantStatus {
+			t.Errorf("writeNotifyACK(success=%v) status = %d, want %d", c.success, w.Code, c.wantStatus)
+		}
+		if body := w.Body.String(); body != c.wantBody {
+			t.Errorf("writeNotifyACK(success=%v) body = %q, want %q", c.success, body, c.wantBody)
+		}
+	}
+}
+
+func TestLRUIdempotencyStoreAlreadyProcessed(t *testing.T) {
+	store := NewLRUIdempotencyStore(2)
+
+	if store.AlreadyProcessed("id1") {
+		t.Fatal(`AlreadyProcessed("id1") = true before MarkProcessed, want false`)
+	}
+	store.MarkProcessed("id1")
+	if !store.AlreadyProcessed("id1") {
+		t.Fatal(`AlreadyProcessed("id1") = false after MarkProcessed, want true`)
+	}
+
+	store.MarkProcessed("id2")
+
+	// 容量是 2, 标记 id3 会淘汰最久未使用的 id1(id2 刚被访问过, 比 id1 新).
+	store.MarkProcessed("id3")
+
+	if store.AlreadyProcessed("id1") {
+		t.Fatal(`AlreadyProcessed("id1") = true after eviction, want false (should look new again)`)
+	}
+}
+
+func TestRedisIdempotencyStoreFailsOpen(t *testing.T) {
+	store := &RedisIdempotencyStore{
+		Exists: func(notifyId string) (bool, error) {
+			return false, errors.New("redis unavailable")
+		},
+		Set: func(notifyId string) error {
+			return errors.New("redis unavailable")
+		},
+	}
+	if store.AlreadyProcessed("id1") {
+		t.Fatal("AlreadyProcessed with unavailable Redis should fail open (return false), got true")
+	}
+	store.MarkProcessed("id1") // Set 失败时 MarkProcessed 不应该 panic 或者向上传播错误
+}
+
+// TestNotifyHandlerRetriesFailedHandle 验证: 如果业务回调第一次处理失败, 幂等 store 不能把
+// 这次失败当成"已处理", 否则微信按协议重试同一个 NotifyId 时会被直接 ACK success 而不再调用回调,
+// 导致这笔通知永远没有被成功处理却让微信以为已经处理了.
+func TestNotifyHandlerRetriesFailedHandle(t *testing.T) {
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+	values := url.Values{
+		"notify_id":      {"notify-id-1"},
+		"trade_mode":     {"1"},
+		"trade_state":    {"0"},
+		"transaction_id": {"1900000109201407033233368018"},
+		"time_end":       {"20140703233359"},
+		"bank_type":      {"WX"},
+		"partner":        {"1900000109"},
+		"out_trade_no":   {"1217752501201407033233368018"},
+		"total_fee":      {"101"},
+		"fee_type":       {"1"},
+	}
+	sum := md5.Sum(signString(values, paySignKey))
+	values.Set("sign", strings.ToUpper(hex.EncodeToString(sum[:])))
+
+	calls := 0
+	failFirst := NotifyHandlerFunc(func(ctx context.Context, n *NotifyURLData) error {
+		calls++
+		if calls == 1 {
+			return errors.New("business error, e.g. duplicate order lookup failed transiently")
+		}
+		return nil
+	})
+
+	handler := NewNotifyHandler(paySignKey, failFirst)
+
+	req1 := httptest.NewRequest(http.MethodGet, "/notify?"+values.Encode(), nil)
+	w1 := httptest.NewRecorder()
+	handler.ServeHTTP(w1, req1)
+	if w1.Code != http.StatusInternalServerError {
+		t.Fatalf("first delivery: status = %d, want %d", w1.Code, http.StatusInternalServerError)
+	}
+	if calls != 1 {
+		t.Fatalf("first delivery: handle called %d times, want 1", calls)
+	}
+
+	// 微信按协议重试同一个 NotifyId.
+	req2 := httptest.NewRequest(http.MethodGet, "/notify?"+values.Encode(), nil)
+	w2 := httptest.NewRecorder()
+	handler.ServeHTTP(w2, req2)
+	if w2.Code != http.StatusOK {
+		t.Fatalf("retry: status = %d, want %d", w2.Code, http.StatusOK)
+	}
+	if calls != 2 {
+		t.Fatalf("retry: handle called %d times in total, want 2 (must be retried after first failure)", calls)
+	}
+
+	// 再收到一次同样的通知, 这次应该直接 ACK, 不再调用业务回调.
+	req3 := httptest.NewRequest(http.MethodGet, "/notify?"+values.Encode(), nil)
+	w3 := httptest.NewRecorder()
+	handler.ServeHTTP(w3, req3)
+	if w3.Code != http.StatusOK {
+		t.Fatalf("third delivery: status = %d, want %d", w3.Code, http.StatusOK)
+	}
+	if calls != 2 {
+		t.Fatalf("third delivery: handle called %d times in total, want 2 (should be skipped as already processed)", calls)
+	}
+}
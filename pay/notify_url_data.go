@@ -7,7 +7,10 @@ package pay
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/subtle"
 	"encoding/hex"
 	"errors"
@@ -17,6 +20,9 @@ import (
 	"time"
 )
 
+// NOTIFY_URL_DATA_SIGN_METHOD_RSA 表示通知消息使用 RSA 签名, 见 CheckAndInitWithSignKeyProvider.
+const NOTIFY_URL_DATA_SIGN_METHOD_RSA = "RSA"
+
 // 支付成功后通知消息 url query string 部分
 type NotifyURLData struct {
 	// 协议参数 ==================================================================
@@ -56,20 +62,85 @@ type NotifyURLData struct {
 // 根据 values url.Values(来自对 notify url query string 的解析) 来初始化 data *NotifyURLData.
 // 如果 values url.Values 里的参数不合法(包括签名不正确) 则返回错误信息, 否则返回 nil.
 //  @paySignKey: 公众号支付请求中用于加密的密钥 Key, 对应于支付场景中的 appKey
+//
+// 注意: CheckAndInit 只支持固定单密钥的 MD5 验签. 如果通知消息可能是 sign_type=RSA 或者
+// 启用了 sign_key_index 多密钥轮换, 必须改用 CheckAndInitWithSignKeyProvider, 否则
+// 这里会拿 paySignKey 去跑 MD5 验签, 在错误的密钥空间里失败, 报一个容易让人摸不着头脑的签名错误.
 func (data *NotifyURLData) CheckAndInit(values url.Values, paySignKey string) (err error) {
 	if values == nil {
 		return errors.New("values == nil")
 	}
 
-	// 先检查签名是否正确 =========================================================
+	signature, err := popSignature(values)
+	if err != nil {
+		return err
+	}
+	if err = checkSignatureMD5(values, signature, paySignKey); err != nil {
+		return err
+	}
+
+	return data.initFields(values, signature)
+}
+
+// CheckAndInitWithSignKeyProvider 和 CheckAndInit 类似, 但是通过 provider 支持多密钥轮换以及
+// sign_type=RSA 的通知消息. 具体使用哪个密钥由通知消息里的 sign_key_index 决定,
+// 具体使用哪种签名算法由通知消息里的 sign_type 决定(未指定时默认为 MD5).
+func (data *NotifyURLData) CheckAndInitWithSignKeyProvider(values url.Values, provider SignKeyProvider) (err error) {
+	if values == nil {
+		return errors.New("values == nil")
+	}
+
+	signature, err := popSignature(values)
+	if err != nil {
+		return err
+	}
+
+	signKeyIndex := 1
+	if signKeyIndexes := values["sign_key_index"]; len(signKeyIndexes) > 0 && len(signKeyIndexes[0]) > 0 {
+		index, err := strconv.ParseInt(signKeyIndexes[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		signKeyIndex = int(index)
+	}
+
+	switch signType := values.Get("sign_type"); signType {
+	case "", NOTIFY_URL_DATA_SIGN_METHOD_MD5:
+		key, err := provider.MD5Key(signKeyIndex)
+		if err != nil {
+			return err
+		}
+		if err = checkSignatureMD5(values, signature, key); err != nil {
+			return err
+		}
+	case NOTIFY_URL_DATA_SIGN_METHOD_RSA:
+		pub, err := provider.RSAPublicKey(signKeyIndex)
+		if err != nil {
+			return err
+		}
+		if err = checkSignatureRSA(values, signature, pub); err != nil {
+			return err
+		}
+	default:
+		return errors.New("pay: unknown sign_type: " + signType)
+	}
+
+	return data.initFields(values, signature)
+}
 
+// popSignature 取出 values 里的 sign 字段(验签之后该字段不应再参与签名字符串的计算).
+func popSignature(values url.Values) (signature string, err error) {
 	signatures := values["sign"]
 	if len(signatures) == 0 || len(signatures[0]) == 0 {
-		return errors.New("sign is empty")
-	} else {
-		values.Del("sign")
+		return "", errors.New("sign is empty")
 	}
+	values.Del("sign")
+	return signatures[0], nil
+}
 
+// signString 把 values 按 key 排序后拼接成 k1=v1&k2=v2&... 的形式.
+// 如果 key != "" 则在末尾追加 &key=<key>, 用于 MD5/HMAC-SHA256 签名.
+func signString(values url.Values, key string) []byte {
 	keys := make([]string, 0, len(values))
 	for k := range values {
 		keys = append(keys, k)
@@ -89,24 +160,44 @@ func (data *NotifyURLData) CheckAndInit(values url.Values, paySignKey string) (e
 			buf.WriteString(v)
 		}
 	}
-	if buf.Len() > 0 {
-		buf.WriteByte('&')
+	if key != "" {
+		if buf.Len() > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString("key=")
+		buf.WriteString(key)
 	}
-	buf.WriteString("key=")
-	buf.WriteString(paySignKey)
+	return buf.Bytes()
+}
 
-	string1 := buf.Bytes()
-	hashSumArray := md5.Sum(string1)
+// checkSignatureMD5 校验 sign_type=MD5(或未指定 sign_type) 的通知消息签名.
+func checkSignatureMD5(values url.Values, signature, paySignKey string) error {
+	hashSumArray := md5.Sum(signString(values, paySignKey))
 	hashSumHexBytes := make([]byte, hex.EncodedLen(len(hashSumArray)))
 	hex.Encode(hashSumHexBytes, hashSumArray[:])
 	copy(hashSumHexBytes, bytes.ToUpper(hashSumHexBytes))
 
-	if subtle.ConstantTimeCompare([]byte(signatures[0]), hashSumHexBytes) != 1 {
-		return errors.New("签名检验不通过")
+	if subtle.ConstantTimeCompare([]byte(signature), hashSumHexBytes) != 1 {
+		return ErrSignatureInvalid
 	}
+	return nil
+}
 
-	// 初始化 ===================================================================
+// checkSignatureRSA 校验 sign_type=RSA 的通知消息签名, 签名串不带 &key=... 后缀.
+func checkSignatureRSA(values url.Values, signature string, pub *rsa.PublicKey) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	hashSumArray := sha1.Sum(signString(values, ""))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA1, hashSumArray[:], sig); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
 
+// initFields 在签名校验通过之后, 用 values 和已验证的 signature 填充 data 的各个字段.
+func (data *NotifyURLData) initFields(values url.Values, signature string) (err error) {
 	if serviceVersions := values["service_version"]; len(serviceVersions) > 0 && len(serviceVersions[0]) > 0 {
 		data.ServiceVersion = serviceVersions[0]
 	} else {
@@ -120,7 +211,7 @@ func (data *NotifyURLData) CheckAndInit(values url.Values, paySignKey string) (e
 	}
 
 	// 在上面已经判断了
-	data.Signature = signatures[0]
+	data.Signature = signature
 
 	if signMethods := values["sign_type"]; len(signMethods) > 0 && len(signMethods[0]) > 0 {
 		data.SignMethod = signMethods[0]
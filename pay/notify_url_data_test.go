@@ -0,0 +1,82 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+func TestCheckSignatureMD5(t *testing.T) {
+	values := url.Values{
+		"out_trade_no": {"1217752501201407033233368018"},
+		"total_fee":    {"101"},
+	}
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+	// 用 checkSignatureMD5 自己依赖的 signString 现算签名, 不依赖 request.go 里的 SignParams,
+	// 这样验签的测试不会受下行请求签名实现的影响.
+	hashSumArray := md5.Sum(signString(values, paySignKey))
+	signature := string(bytes.ToUpper([]byte(hex.EncodeToString(hashSumArray[:]))))
+
+	if err := checkSignatureMD5(values, signature, paySignKey); err != nil {
+		t.Fatalf("checkSignatureMD5 with valid signature: %v", err)
+	}
+
+	if err := checkSignatureMD5(values, signature, "wrong-key"); err != ErrSignatureInvalid {
+		t.Fatalf("checkSignatureMD5 with wrong key: got %v, want ErrSignatureInvalid", err)
+	}
+
+	if err := checkSignatureMD5(values, "0123456789abcdef0123456789abcdef", paySignKey); err != ErrSignatureInvalid {
+		t.Fatalf("checkSignatureMD5 with tampered signature: got %v, want ErrSignatureInvalid", err)
+	}
+}
+
+func TestCheckSignatureRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	values := url.Values{
+		"out_trade_no": {"1217752501201407033233368018"},
+		"total_fee":    {"101"},
+	}
+
+	h := sha1.Sum(signString(values, ""))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA1, h[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	signature := hex.EncodeToString(sig)
+
+	if err := checkSignatureRSA(values, signature, &priv.PublicKey); err != nil {
+		t.Fatalf("checkSignatureRSA with valid signature: %v", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if err := checkSignatureRSA(values, signature, &other.PublicKey); err != ErrSignatureInvalid {
+		t.Fatalf("checkSignatureRSA with wrong public key: got %v, want ErrSignatureInvalid", err)
+	}
+
+	tampered := url.Values{
+		"out_trade_no": {"1217752501201407033233368018"},
+		"total_fee":    {"999"},
+	}
+	if err := checkSignatureRSA(tampered, signature, &priv.PublicKey); err != ErrSignatureInvalid {
+		t.Fatalf("checkSignatureRSA with tampered params: got %v, want ErrSignatureInvalid", err)
+	}
+}
@@ -0,0 +1,186 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// notify_v3 相关的错误.
+var (
+	ErrNotifyV3TimestampExpired = errors.New("pay: Wechatpay-Timestamp too far from wall clock")
+	ErrNotifyV3UnknownAlgorithm = errors.New("pay: unsupported resource.algorithm")
+)
+
+// notifyV3MaxClockSkew 是 Wechatpay-Timestamp 允许偏离本地时间的最大值.
+const notifyV3MaxClockSkew = 5 * time.Minute
+
+// NotifyV3 是微信支付 v3 版本支付结果通知解密之后的数据.
+type NotifyV3 struct {
+	ID         string    // 通知的唯一 id
+	CreateTime time.Time // 通知创建的时间
+	EventType  string    // 通知的类型, 支付成功通知为 TRANSACTION.SUCCESS
+
+	OutTradeNo    string    // 商户系统内部订单号
+	TransactionId string    // 微信支付系统生成的订单号
+	TradeState    string    // 交易状态, SUCCESS-支付成功
+	PayerOpenId   string    // 支付者在商户 appid 下的 openid
+	Amount        int       // 订单总金额, 单位为分
+	SuccessTime   time.Time // 支付完成时间
+}
+
+// notifyV3Envelope 对应 v3 通知的 JSON 信封, resource 字段是加密过的业务数据.
+type notifyV3Envelope struct {
+	ID         string `json:"id"`
+	CreateTime string `json:"create_time"`
+	EventType  string `json:"event_type"`
+	Resource   struct {
+		Algorithm      string `json:"algorithm"`
+		Ciphertext     string `json:"ciphertext"`
+		Nonce          string `json:"nonce"`
+		AssociatedData string `json:"associated_data"`
+	} `json:"resource"`
+}
+
+// notifyV3Resource 是 resource 解密之后的交易结果 JSON.
+type notifyV3Resource struct {
+	OutTradeNo    string `json:"out_trade_no"`
+	TransactionId string `json:"transaction_id"`
+	TradeState    string `json:"trade_state"`
+	SuccessTime   string `json:"success_time"`
+	Payer         struct {
+		OpenId string `json:"openid"`
+	} `json:"payer"`
+	Amount struct {
+		Total int `json:"total"`
+	} `json:"amount"`
+}
+
+// ParseNotifyV3 解析并验证微信支付 v3 版本的支付结果通知.
+//
+// body 是 http 请求的原始 body(验签需要用到原始字节, 不能先 json.Unmarshal 再重新序列化);
+// headers 是该请求的 http.Header, 须包含 Wechatpay-Timestamp, Wechatpay-Nonce,
+// Wechatpay-Signature, Wechatpay-Serial 这几个字段;
+// apiV3Key 是商户平台设置的 APIv3 密钥, 用于 AES-256-GCM 解密 resource;
+// certStore 用于根据 Wechatpay-Serial 查找对应的微信支付平台证书公钥.
+func ParseNotifyV3(body []byte, headers http.Header, apiV3Key string, certStore CertStore) (*NotifyV3, error) {
+	timestamp := headers.Get("Wechatpay-Timestamp")
+	nonce := headers.Get("Wechatpay-Nonce")
+	signature := headers.Get("Wechatpay-Signature")
+	serial := headers.Get("Wechatpay-Serial")
+	if timestamp == "" || nonce == "" || signature == "" || serial == "" {
+		return nil, errors.New("pay: missing Wechatpay-* header")
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if d := time.Since(time.Unix(sec, 0)); d > notifyV3MaxClockSkew || d < -notifyV3MaxClockSkew {
+		return nil, ErrNotifyV3TimestampExpired
+	}
+
+	pub, err := certStore.PublicKey(serial)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyNotifyV3Signature(pub, timestamp, nonce, body, signature); err != nil {
+		return nil, err
+	}
+
+	var envelope notifyV3Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, ErrNotifyV3UnknownAlgorithm
+	}
+
+	plaintext, err := aes256gcmDecrypt(
+		apiV3Key,
+		envelope.Resource.Nonce,
+		envelope.Resource.AssociatedData,
+		envelope.Resource.Ciphertext,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var resource notifyV3Resource
+	if err := json.Unmarshal(plaintext, &resource); err != nil {
+		return nil, err
+	}
+
+	createTime, err := time.Parse(time.RFC3339, envelope.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	var successTime time.Time
+	if resource.SuccessTime != "" {
+		successTime, err = time.Parse(time.RFC3339, resource.SuccessTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &NotifyV3{
+		ID:            envelope.ID,
+		CreateTime:    createTime,
+		EventType:     envelope.EventType,
+		OutTradeNo:    resource.OutTradeNo,
+		TransactionId: resource.TransactionId,
+		TradeState:    resource.TradeState,
+		PayerOpenId:   resource.Payer.OpenId,
+		Amount:        resource.Amount.Total,
+		SuccessTime:   successTime,
+	}, nil
+}
+
+// verifyNotifyV3Signature 校验 Wechatpay-Signature, message 构造规则见微信支付 v3 文档:
+//
+//	message = timestamp + "\n" + nonce + "\n" + body + "\n"
+func verifyNotifyV3Signature(pub *rsa.PublicKey, timestamp, nonce string, body []byte, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	message := timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	h := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], sig); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// aes256gcmDecrypt 用 apiV3Key 作为密钥, nonce 作为 IV, associatedData 作为 AAD,
+// 对 base64 编码的 ciphertext 做 AES-256-GCM 解密.
+func aes256gcmDecrypt(apiV3Key, nonce, associatedData, ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
@@ -0,0 +1,209 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAES256GCMDecrypt(t *testing.T) {
+	const apiV3Key = "0123456789abcdef0123456789abcdef" // 32 字节
+	const nonce = "0123456789ab"                        // 12 字节
+	const associatedData = "transaction"
+	const plaintext = `{"out_trade_no":"T123","trade_state":"SUCCESS"}`
+
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("NewGCM: %v", err)
+	}
+	sealed := gcm.Seal(nil, []byte(nonce), []byte(plaintext), []byte(associatedData))
+	ciphertext := base64.StdEncoding.EncodeToString(sealed)
+
+	got, err := aes256gcmDecrypt(apiV3Key, nonce, associatedData, ciphertext)
+	if err != nil {
+		t.Fatalf("aes256gcmDecrypt: %v", err)
+	}
+	if string(got) != plaintext {
+		t.Fatalf("aes256gcmDecrypt = %q, want %q", got, plaintext)
+	}
+
+	if _, err := aes256gcmDecrypt(apiV3Key, nonce, "wrong-associated-data", ciphertext); err == nil {
+		t.Fatal("aes256gcmDecrypt with wrong associated_data: want error, got nil")
+	}
+
+	if _, err := aes256gcmDecrypt("wrong-key-wrong-key-wrong-key-32", nonce, associatedData, ciphertext); err == nil {
+		t.Fatal("aes256gcmDecrypt with wrong key: want error, got nil")
+	}
+}
+
+func TestVerifyNotifyV3Signature(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	const timestamp = "1614143415"
+	const nonce = "L2Mw1tTnGr"
+	body := []byte(`{"id":"EV-2018022511223320873","event_type":"TRANSACTION.SUCCESS"}`)
+
+	message := timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	h := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	if err := verifyNotifyV3Signature(&priv.PublicKey, timestamp, nonce, body, signature); err != nil {
+		t.Fatalf("verifyNotifyV3Signature with valid signature: %v", err)
+	}
+
+	if err := verifyNotifyV3Signature(&priv.PublicKey, timestamp, nonce, []byte("tampered body"), signature); err != ErrSignatureInvalid {
+		t.Fatalf("verifyNotifyV3Signature with tampered body: got %v, want ErrSignatureInvalid", err)
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if err := verifyNotifyV3Signature(&other.PublicKey, timestamp, nonce, body, signature); err != ErrSignatureInvalid {
+		t.Fatalf("verifyNotifyV3Signature with wrong public key: got %v, want ErrSignatureInvalid", err)
+	}
+}
+
+// fakeCertStore 是测试用的 CertStore 实现, serial -> 公钥的映射由调用方直接指定.
+type fakeCertStore map[string]*rsa.PublicKey
+
+func (s fakeCertStore) PublicKey(serialNo string) (*rsa.PublicKey, error) {
+	pub, ok := s[serialNo]
+	if !ok {
+		return nil, ErrUnknownCertSerial
+	}
+	return pub, nil
+}
+
+// signedNotifyV3Request 构造一个完整的、签过名的 v3 通知请求(body + headers), 供 ParseNotifyV3
+// 端到端测试使用; timestamp 留给调用方指定, 以便覆盖时间戳校验的边界情况.
+func signedNotifyV3Request(t *testing.T, priv *rsa.PrivateKey, serial, apiV3Key, timestamp string) ([]byte, http.Header) {
+	t.Helper()
+
+	const apiV3Nonce = "gZiqzqz8abcd" // 12 字节
+	const associatedData = "transaction"
+	const plaintext = `{"out_trade_no":"1217752501201407033233368018","transaction_id":"1900000109201407033233368018",` +
+		`"trade_state":"SUCCESS","success_time":"2014-07-03T23:33:59+08:00",` +
+		`"payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":101}}`
+
+	block, err := aes.NewCipher([]byte(apiV3Key))
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("NewGCM: %v", err)
+	}
+	sealed := gcm.Seal(nil, []byte(apiV3Nonce), []byte(plaintext), []byte(associatedData))
+	ciphertext := base64.StdEncoding.EncodeToString(sealed)
+
+	body := []byte(fmt.Sprintf(
+		`{"id":"EV-2014070323335901","create_time":"2014-07-03T23:33:59+08:00","event_type":"TRANSACTION.SUCCESS",`+
+			`"resource":{"algorithm":"AEAD_AES_256_GCM","ciphertext":%q,"nonce":%q,"associated_data":%q}}`,
+		ciphertext, apiV3Nonce, associatedData))
+
+	const nonce = "L2Mw1tTnGr"
+	message := timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	h := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("Wechatpay-Timestamp", timestamp)
+	headers.Set("Wechatpay-Nonce", nonce)
+	headers.Set("Wechatpay-Signature", base64.StdEncoding.EncodeToString(sig))
+	headers.Set("Wechatpay-Serial", serial)
+	return body, headers
+}
+
+func TestParseNotifyV3(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	const serial = "5157F09EFDC096DE15EBE81A47057A7232F1B8E1"
+	const apiV3Key = "0123456789abcdef0123456789abcdef" // 32 字节
+	certStore := fakeCertStore{serial: &priv.PublicKey}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body, headers := signedNotifyV3Request(t, priv, serial, apiV3Key, timestamp)
+
+	notify, err := ParseNotifyV3(body, headers, apiV3Key, certStore)
+	if err != nil {
+		t.Fatalf("ParseNotifyV3: %v", err)
+	}
+
+	if notify.ID != "EV-2014070323335901" {
+		t.Errorf("ID = %q, want %q", notify.ID, "EV-2014070323335901")
+	}
+	if notify.EventType != "TRANSACTION.SUCCESS" {
+		t.Errorf("EventType = %q, want %q", notify.EventType, "TRANSACTION.SUCCESS")
+	}
+	if notify.OutTradeNo != "1217752501201407033233368018" {
+		t.Errorf("OutTradeNo = %q, want %q", notify.OutTradeNo, "1217752501201407033233368018")
+	}
+	if notify.TransactionId != "1900000109201407033233368018" {
+		t.Errorf("TransactionId = %q, want %q", notify.TransactionId, "1900000109201407033233368018")
+	}
+	if notify.TradeState != "SUCCESS" {
+		t.Errorf("TradeState = %q, want %q", notify.TradeState, "SUCCESS")
+	}
+	if notify.PayerOpenId != "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o" {
+		t.Errorf("PayerOpenId = %q, want %q", notify.PayerOpenId, "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o")
+	}
+	if notify.Amount != 101 {
+		t.Errorf("Amount = %d, want %d", notify.Amount, 101)
+	}
+	wantCreateTime, _ := time.Parse(time.RFC3339, "2014-07-03T23:33:59+08:00")
+	if !notify.CreateTime.Equal(wantCreateTime) {
+		t.Errorf("CreateTime = %v, want %v", notify.CreateTime, wantCreateTime)
+	}
+	wantSuccessTime, _ := time.Parse(time.RFC3339, "2014-07-03T23:33:59+08:00")
+	if !notify.SuccessTime.Equal(wantSuccessTime) {
+		t.Errorf("SuccessTime = %v, want %v", notify.SuccessTime, wantSuccessTime)
+	}
+}
+
+func TestParseNotifyV3RejectsExpiredTimestamp(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	const serial = "5157F09EFDC096DE15EBE81A47057A7232F1B8E1"
+	const apiV3Key = "0123456789abcdef0123456789abcdef" // 32 字节
+	certStore := fakeCertStore{serial: &priv.PublicKey}
+
+	staleTimestamp := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	body, headers := signedNotifyV3Request(t, priv, serial, apiV3Key, staleTimestamp)
+
+	if _, err := ParseNotifyV3(body, headers, apiV3Key, certStore); err != ErrNotifyV3TimestampExpired {
+		t.Fatalf("ParseNotifyV3 with stale timestamp: got %v, want ErrNotifyV3TimestampExpired", err)
+	}
+}
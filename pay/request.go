@@ -0,0 +1,208 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 请求签名类型, 和 NOTIFY_URL_DATA_SIGN_METHOD_* 对应的是通知消息里的 sign_type,
+// 这里是下行请求(统一下单, 退款, 关单, 企业付款等)支持的签名类型.
+const (
+	SIGN_TYPE_MD5         = "MD5"
+	SIGN_TYPE_HMAC_SHA256 = "HMAC-SHA256"
+)
+
+// SignParams 对 values 计算微信支付请求的签名, 算法和 NotifyURLData.CheckAndInit 里验签用的完全一致:
+// 按 key 排序拼成 k1=v1&k2=v2&...&key=paySignKey, 再以 signType 指定的算法摘要并转成大写十六进制串.
+//
+//	signType: "" 或 SIGN_TYPE_MD5 时使用 MD5, SIGN_TYPE_HMAC_SHA256 时使用 HMAC-SHA256(以 paySignKey 为密钥)
+func SignParams(values url.Values, signType, paySignKey string) (string, error) {
+	switch signType {
+	case "", SIGN_TYPE_MD5:
+		sum := md5.Sum(signString(values, paySignKey))
+		return strings.ToUpper(hex.EncodeToString(sum[:])), nil
+	case SIGN_TYPE_HMAC_SHA256:
+		mac := hmac.New(sha256.New, []byte(paySignKey))
+		mac.Write(signString(values, paySignKey))
+		return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))), nil
+	default:
+		return "", fmt.Errorf("pay: unknown sign_type: %s", signType)
+	}
+}
+
+// Request 是下行请求(统一下单, 退款, 关单, 企业付款等) 的参数构造器.
+// 典型用法: 用 NewRequest 把一个带 `pay` tag 的 struct 转成 Request, 调用 Sign 签名,
+// 然后 xml.Marshal(req) 得到可以直接作为请求 body 的 XML.
+type Request struct {
+	values url.Values
+}
+
+// NewRequest 通过反射把 v (struct 或者 struct 指针) 转成 Request.
+// v 的每个导出字段需要有形如 `pay:"field_name,omitempty"` 的 tag 才会被收录,
+// 没有 tag 或者 tag 是 "-" 的字段会被忽略; omitempty 时零值字段不会出现在参数里.
+func NewRequest(v interface{}) (*Request, error) {
+	values, err := structToValues(v)
+	if err != nil {
+		return nil, err
+	}
+	return &Request{values: values}, nil
+}
+
+// Values 返回 Request 内部的参数, 调用方可以在 Sign 之前继续增删字段(比如补充 nonce_str).
+func (r *Request) Values() url.Values {
+	return r.values
+}
+
+// Sign 计算签名并写入 sign(以及非空 signType 时的 sign_type) 字段.
+// sign_type 必须先写入 values 再参与签名计算, 否则服务端按收到的报文(带 sign_type) 重新算出的
+// 签名会和这里算出来的对不上.
+func (r *Request) Sign(signType, paySignKey string) error {
+	if signType != "" {
+		r.values.Set("sign_type", signType)
+	}
+	sign, err := SignParams(r.values, signType, paySignKey)
+	if err != nil {
+		return err
+	}
+	r.values.Set("sign", sign)
+	return nil
+}
+
+// MarshalXML 实现 xml.Marshaler, 把参数序列化成微信支付请求报文的格式: <xml><k>v</k>...</xml>.
+func (r *Request) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "xml"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for k, vs := range r.values {
+		for _, v := range vs {
+			if err := e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+				return err
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Response 是微信支付 XML 响应报文(统一下单, 退款等接口的返回值) 的通用解析结果,
+// key 是 XML 子元素名, value 是其文本内容, 用法: var resp Response; xml.Unmarshal(body, &resp).
+type Response map[string]string
+
+// Get 返回 key 对应的字段值, 不存在时返回 "".
+func (r Response) Get(key string) string {
+	return r[key]
+}
+
+// UnmarshalXML 实现 xml.Unmarshaler.
+func (r *Response) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*r = make(Response)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			var value string
+			if err := d.DecodeElement(&value, &t); err != nil {
+				return err
+			}
+			(*r)[t.Name.Local] = value
+		case xml.EndElement:
+			if t.Name == start.Name {
+				return nil
+			}
+		}
+	}
+}
+
+// structToValues 把 v 按 `pay` tag 转成 url.Values.
+func structToValues(v interface{}) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, errors.New("pay: v is a nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errors.New("pay: v must be a struct or a pointer to struct")
+	}
+	rt := rv.Type()
+
+	values := make(url.Values, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("pay")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := tag
+		omitempty := false
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name = tag[:idx]
+			omitempty = tag[idx+1:] == "omitempty"
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fv := rv.Field(i)
+		if omitempty && isEmptyValue(fv) {
+			continue
+		}
+		values.Set(name, formatValue(fv))
+	}
+	return values, nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	default:
+		return false
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
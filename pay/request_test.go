@@ -0,0 +1,152 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSignParams(t *testing.T) {
+	values := url.Values{
+		"appid":       {"wxd930ea5d5a258f4f"},
+		"mch_id":      {"10000100"},
+		"device_info": {"1000"},
+		"body":        {"test"},
+		"nonce_str":   {"ibuaiVcKdpRxkhJA"},
+	}
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+
+	cases := []struct {
+		name     string
+		signType string
+	}{
+		{name: "MD5 default", signType: ""},
+		{name: "MD5 explicit", signType: SIGN_TYPE_MD5},
+		{name: "HMAC-SHA256", signType: SIGN_TYPE_HMAC_SHA256},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sign, err := SignParams(values, c.signType, paySignKey)
+			if err != nil {
+				t.Fatalf("SignParams: %v", err)
+			}
+			if sign == "" {
+				t.Fatal("SignParams returned empty signature")
+			}
+			if sign != strings.ToUpper(sign) {
+				t.Fatalf("SignParams signature %q is not uppercase", sign)
+			}
+
+			// 签名必须是确定性的, 和字段顺序无关(SignParams 内部会排序).
+			again, err := SignParams(values, c.signType, paySignKey)
+			if err != nil {
+				t.Fatalf("SignParams (again): %v", err)
+			}
+			if again != sign {
+				t.Fatalf("SignParams is not deterministic: %q != %q", again, sign)
+			}
+		})
+	}
+
+	sign, err := SignParams(values, "", paySignKey)
+	if err != nil {
+		t.Fatalf("SignParams: %v", err)
+	}
+	if other, _ := SignParams(values, "", "different-key"); other == sign {
+		t.Fatal("SignParams produced the same signature for two different keys")
+	}
+
+	if _, err := SignParams(values, "UNKNOWN", paySignKey); err == nil {
+		t.Fatal("SignParams with unknown sign_type: want error, got nil")
+	}
+}
+
+type signParamsTestStruct struct {
+	AppId      string `pay:"appid"`
+	OutTradeNo string `pay:"out_trade_no,omitempty"`
+	TotalFee   int    `pay:"total_fee"`
+	Ignored    string // 没有 tag, 不应该出现在结果里
+	Skipped    string `pay:"-"`
+}
+
+func TestStructToValues(t *testing.T) {
+	v := signParamsTestStruct{
+		AppId:      "wxd930ea5d5a258f4f",
+		OutTradeNo: "",
+		TotalFee:   101,
+		Ignored:    "should not appear",
+		Skipped:    "should not appear either",
+	}
+
+	values, err := structToValues(v)
+	if err != nil {
+		t.Fatalf("structToValues: %v", err)
+	}
+
+	if got := values.Get("appid"); got != "wxd930ea5d5a258f4f" {
+		t.Fatalf("appid = %q, want %q", got, "wxd930ea5d5a258f4f")
+	}
+	if got := values.Get("total_fee"); got != "101" {
+		t.Fatalf("total_fee = %q, want %q", got, "101")
+	}
+	if values.Get("Ignored") != "" || values.Get("ignored") != "" {
+		t.Fatal("field without `pay` tag leaked into values")
+	}
+	if values.Get("Skipped") != "" {
+		t.Fatal(`field tagged pay:"-" leaked into values`)
+	}
+	if _, ok := values["out_trade_no"]; ok {
+		t.Fatal("empty out_trade_no with omitempty should be omitted, but was present")
+	}
+
+	v.OutTradeNo = "1217752501201407033233368018"
+	values, err = structToValues(v)
+	if err != nil {
+		t.Fatalf("structToValues: %v", err)
+	}
+	if got := values.Get("out_trade_no"); got != v.OutTradeNo {
+		t.Fatalf("out_trade_no = %q, want %q", got, v.OutTradeNo)
+	}
+
+	if _, err := structToValues("not a struct"); err == nil {
+		t.Fatal("structToValues with non-struct: want error, got nil")
+	}
+}
+
+func TestRequestSignRoundTrip(t *testing.T) {
+	req, err := NewRequest(signParamsTestStruct{
+		AppId:      "wxd930ea5d5a258f4f",
+		OutTradeNo: "1217752501201407033233368018",
+		TotalFee:   101,
+	})
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	const paySignKey = "192006250b4c09247ec02edce69f6a2d"
+	if err := req.Sign(SIGN_TYPE_MD5, paySignKey); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	sign := req.Values().Get("sign")
+	if sign == "" {
+		t.Fatal("Sign did not set the sign field")
+	}
+
+	values := make(url.Values, len(req.Values()))
+	for k, vs := range req.Values() {
+		if k == "sign" {
+			continue
+		}
+		values[k] = vs
+	}
+	if err := checkSignatureMD5(values, sign, paySignKey); err != nil {
+		t.Fatalf("signature produced by Request.Sign does not verify: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+// @description wechat 是腾讯微信公众平台 api 的 golang 语言封装
+// @link        https://github.com/chanxuehong/wechat for the canonical source repository
+// @license     https://github.com/chanxuehong/wechat/blob/master/LICENSE
+// @authors     chanxuehong(chanxuehong@gmail.com)
+
+package pay
+
+import (
+	"crypto/rsa"
+	"errors"
+)
+
+// 签名校验失败, 调用方可以据此区分"参数缺失"之类的错误和真正的验签失败.
+var ErrSignatureInvalid = errors.New("pay: signature invalid")
+
+// 根据 sign_key_index 找不到对应的密钥(MD5 key 或者 RSA 公钥) 时返回.
+var ErrUnknownSignKey = errors.New("pay: unknown sign key index")
+
+// SignKeyProvider 用于支持多密钥轮换以及 RSA 验签, 调用方实现该接口并注册到 CheckAndInitWithSignKeyProvider.
+type SignKeyProvider interface {
+	// MD5Key 返回 signKeyIndex 对应的 MD5 签名密钥(即 paySignKey).
+	// 如果 signKeyIndex 不存在则返回 ErrUnknownSignKey.
+	MD5Key(signKeyIndex int) (key string, err error)
+
+	// RSAPublicKey 返回 signKeyIndex 对应的 RSA 公钥, 用于验证 sign_type=RSA 的通知.
+	// 如果 signKeyIndex 不存在则返回 ErrUnknownSignKey.
+	RSAPublicKey(signKeyIndex int) (pub *rsa.PublicKey, err error)
+}